@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/tratteria/tratteria-agent/service/discovery"
 )
 
 type Config struct {
@@ -19,11 +20,18 @@ type Config struct {
 	AgentInterceptorPort     int
 	HeartBeatIntervalMinutes int
 	MyNamespace              string
+	DiscoveryMode            string
+	AdvertiseMode            string
+	AdvertiseAddress         string
+	RuleCacheMode            string
+	RuleCacheMaxStalenessMin int
 }
 
 func GetAppConfig() *Config {
+	discoveryMode := getOptionalEnv("DISCOVERY_MODE", discovery.ModeStatic)
+
 	return &Config{
-		TconfigdUrl:              parseURL(getEnv("TCONFIGD_URL")),
+		TconfigdUrl:              getTconfigdUrl(discoveryMode),
 		TconfigdSpiffeId:         spiffeid.RequireFromString(getEnv("TCONFIGD_SPIFFE_ID")),
 		ServicePort:              getOptionalEnvAsInt("SERVICE_PORT"),
 		InterceptionMode:         getEnvAsBool("INTERCEPTION_MODE"),
@@ -32,9 +40,29 @@ func GetAppConfig() *Config {
 		AgentInterceptorPort:     getEnvAsInt("AGENT_INTERCEPTOR_PORT"),
 		HeartBeatIntervalMinutes: getEnvAsInt("HEARTBEAT_INTERVAL_MINUTES"),
 		MyNamespace:              getEnv("MY_NAMESPACE"),
+		DiscoveryMode:            discoveryMode,
+		AdvertiseMode:            getOptionalEnv("ADVERTISE_MODE", discovery.AdvertiseModeFirstNonLoopback),
+		AdvertiseAddress:         getOptionalEnv("ADVERTISE_ADDRESS", ""),
+		RuleCacheMode:            getOptionalEnv("RULE_CACHE_MODE", "file"),
+		RuleCacheMaxStalenessMin: getOptionalEnvAsIntOrDefault("RULE_CACHE_MAX_STALENESS_MINUTES", 0),
 	}
 }
 
+// getTconfigdUrl only requires TCONFIGD_URL when discovery is static;
+// DNS, Kubernetes and Consul discovery modes resolve tconfigd's address
+// themselves and don't need a fixed URL.
+func getTconfigdUrl(discoveryMode string) *url.URL {
+	if discoveryMode != discovery.ModeStatic {
+		if rawurl, exists := os.LookupEnv("TCONFIGD_URL"); exists && rawurl != "" {
+			return parseURL(rawurl)
+		}
+
+		return nil
+	}
+
+	return parseURL(getEnv("TCONFIGD_URL"))
+}
+
 func getEnv(key string) string {
 	value, exists := os.LookupEnv(key)
 	if !exists || value == "" {
@@ -55,6 +83,15 @@ func getEnvAsInt(key string) int {
 	return valueInt
 }
 
+func getOptionalEnv(key, fallback string) string {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback
+	}
+
+	return value
+}
+
 func getOptionalEnvAsInt(key string) *int {
 	valueStr, exists := os.LookupEnv(key)
 	if !exists || valueStr == "" {
@@ -69,6 +106,15 @@ func getOptionalEnvAsInt(key string) *int {
 	return &valueInt
 }
 
+func getOptionalEnvAsIntOrDefault(key string, fallback int) int {
+	value := getOptionalEnvAsInt(key)
+	if value == nil {
+		return fallback
+	}
+
+	return *value
+}
+
 func getEnvAsBool(key string) bool {
 	valueStr := getEnv(key)
 	valueBool, err := strconv.ParseBool(valueStr)
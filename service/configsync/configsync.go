@@ -2,15 +2,22 @@ package configsync
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"math/rand"
-	"net"
 	"net/http"
 	"net/url"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/tratteria/tratteria-agent/service/discovery"
+	"github.com/tratteria/tratteria-agent/service/rulecache"
+	"github.com/tratteria/tratteria-agent/service/version"
 	"github.com/tratteria/tratteria-agent/verificationrules/v1alpha1"
 	"go.uber.org/zap"
 )
@@ -20,68 +27,458 @@ const (
 	FAILED_HEARTBEAT_RETRY_INTERVAL = 5 * time.Second
 	REGISTRATION_PATH               = "register"
 	HEARTBEAT_PATH                  = "heartbeat"
+	DEREGISTRATION_PATH             = "deregister"
+
+	tconfigdResolveInterval = 30 * time.Second
+
+	registerMinBackoff = 1 * time.Second
+	registerMaxBackoff = 30 * time.Second
+
+	maxRequestTimeout = 30 * time.Second
 )
 
+// SupportedRuleSchemaVersions lists the verification-rule schema versions
+// this build understands, reported on registration so tconfigd can reject
+// (or downgrade) agents it knows it can't serve.
+var SupportedRuleSchemaVersions = []string{"v1alpha1"}
+
 type Client struct {
 	webhookPort              int
-	webhookIP                string
+	selfAdvertiser           discovery.SelfAdvertiser
+	resolver                 discovery.Resolver
 	tconfigdUrl              *url.URL
+	tconfigdUrlMu            sync.RWMutex
+	tconfigdSpiffeId         spiffeid.ID
 	namespace                string
 	verificationRulesManager v1alpha1.VerificationRulesManager
 	heartbeatInterval        time.Duration
 	tconfigdMtlsClient       *http.Client
 	logger                   *zap.Logger
+
+	resourceVersion   string
+	resourceVersionMu sync.Mutex
+	ctx               context.Context
+	cancel            context.CancelFunc
+
+	agentID          string
+	bootTimestamp    int64
+	heartbeatCounter uint64
+	ruleDecodeErrors uint64
+
+	ruleCache        rulecache.Cache
+	maxRuleStaleness time.Duration
+
+	currentRules   v1alpha1.VerificationRulesTconfigd
+	currentRulesMu sync.Mutex
+
+	lastAppliedRulesHash string
+	rulesSource          string
+	rulesAppliedAt       time.Time
+	rulesStatusMu        sync.Mutex
+
+	leaseID                     string
+	negotiatedRuleSchemaVersion string
+	leaseMu                     sync.RWMutex
 }
 
-func NewClient(webhookPort int, tconfigdUrl *url.URL, tconfigdSpiffeId spiffeid.ID, namespace string, verificationRulesManager v1alpha1.VerificationRulesManager, heartbeatInterval time.Duration, tconfigdMtlsClient *http.Client, logger *zap.Logger) (*Client, error) {
-	webhookIP, err := getLocalIP()
+func NewClient(webhookPort int, resolver discovery.Resolver, tconfigdSpiffeId spiffeid.ID, namespace string, verificationRulesManager v1alpha1.VerificationRulesManager, heartbeatInterval time.Duration, tconfigdMtlsClient *http.Client, selfAdvertiser discovery.SelfAdvertiser, ruleCache rulecache.Cache, maxRuleStaleness time.Duration, logger *zap.Logger) (*Client, error) {
+	tconfigdUrl, err := resolver.Resolve(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tconfigd address: %w", err)
+	}
+
+	agentID, err := loadOrCreateAgentID(defaultAgentIDPath)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to establish agent identity: %w", err)
+	}
+
+	if ruleCache == nil {
+		ruleCache = rulecache.NewInMemoryCache()
 	}
 
 	return &Client{
 		webhookPort:              webhookPort,
-		webhookIP:                webhookIP,
+		selfAdvertiser:           selfAdvertiser,
+		resolver:                 resolver,
 		tconfigdUrl:              tconfigdUrl,
+		tconfigdSpiffeId:         tconfigdSpiffeId,
 		namespace:                namespace,
 		verificationRulesManager: verificationRulesManager,
 		heartbeatInterval:        heartbeatInterval,
 		tconfigdMtlsClient:       tconfigdMtlsClient,
 		logger:                   logger,
+		agentID:                  agentID,
+		bootTimestamp:            time.Now().Unix(),
+		ruleCache:                ruleCache,
+		maxRuleStaleness:         maxRuleStaleness,
 	}, nil
 }
 
+// Status reports where the agent's current verification rules came from
+// and how stale they are, so operators can tell whether an agent is
+// running on live or cached rules.
+type Status struct {
+	RulesSource          string    `json:"rulesSource"`
+	RulesAppliedAt       time.Time `json:"rulesAppliedAt"`
+	RulesAgeSeconds      float64   `json:"rulesAgeSeconds"`
+	LastAppliedRulesHash string    `json:"lastAppliedRulesHash"`
+}
+
+func (c *Client) Status() Status {
+	c.rulesStatusMu.Lock()
+	defer c.rulesStatusMu.Unlock()
+
+	var age float64
+	if !c.rulesAppliedAt.IsZero() {
+		age = time.Since(c.rulesAppliedAt).Seconds()
+	}
+
+	return Status{
+		RulesSource:          c.rulesSource,
+		RulesAppliedAt:       c.rulesAppliedAt,
+		RulesAgeSeconds:      age,
+		LastAppliedRulesHash: c.lastAppliedRulesHash,
+	}
+}
+
+// agentHealth is a compact health snapshot included with every
+// registration and heartbeat, giving tconfigd enough state to detect
+// flapping agents and drive dashboards without a separate metrics scrape.
+type agentHealth struct {
+	RuleDecodeErrorCount uint64 `json:"ruleDecodeErrorCount"`
+	LastAppliedRulesHash string `json:"lastAppliedRulesHash"`
+	GoroutineCount       int    `json:"goroutineCount"`
+}
+
+func (c *Client) healthSnapshot() agentHealth {
+	c.rulesStatusMu.Lock()
+	hash := c.lastAppliedRulesHash
+	c.rulesStatusMu.Unlock()
+
+	return agentHealth{
+		RuleDecodeErrorCount: atomic.LoadUint64(&c.ruleDecodeErrors),
+		LastAppliedRulesHash: hash,
+		GoroutineCount:       runtime.NumGoroutine(),
+	}
+}
+
+// applyRules replaces the entire rule set in the verification rules
+// manager and in the client's local copy (currentRules), used for a full
+// snapshot from registration, the rules stream, or the rule cache.
+func (c *Client) applyRules(ctx context.Context, rules v1alpha1.VerificationRulesTconfigd, source string, persist bool) {
+	c.verificationRulesManager.UpdateCompleteRules(rules)
+
+	c.currentRulesMu.Lock()
+	c.currentRules = rules
+	c.currentRulesMu.Unlock()
+
+	c.recordAppliedRules(ctx, rules, source, persist)
+}
+
+// applyRuleDelta merges an incremental update into the client's local
+// copy of the current rule set and pushes just the delta into the
+// verification rules manager via ApplyRuleDelta, instead of replacing the
+// whole set the way applyRules does. The merged set is what gets hashed,
+// recorded in Status, and persisted to the rule cache, so a later outage
+// falls back on the fully-reconciled rules rather than just the last
+// delta.
+func (c *Client) applyRuleDelta(ctx context.Context, upserts map[string]v1alpha1.TraTVerificationRule, deletes []string, source string, persist bool) {
+	c.verificationRulesManager.ApplyRuleDelta(upserts, deletes)
+
+	c.currentRulesMu.Lock()
+	if c.currentRules.TraTs == nil {
+		c.currentRules.TraTs = make(map[string]v1alpha1.TraTVerificationRule, len(upserts))
+	}
+
+	for name, rule := range upserts {
+		c.currentRules.TraTs[name] = rule
+	}
+
+	for _, name := range deletes {
+		delete(c.currentRules.TraTs, name)
+	}
+
+	merged := c.currentRules
+	c.currentRulesMu.Unlock()
+
+	c.recordAppliedRules(ctx, merged, source, persist)
+}
+
+// recordAppliedRules hashes rules (the client's fully-reconciled rule
+// set, not just a delta) and records where they came from ("live" or a
+// rulecache.Cache source string) for Status and the health snapshot.
+// Rules fetched live from tconfigd are also persisted to the cache so a
+// later outage has something to fall back on, but only when the rule
+// content actually changed since the last apply - otherwise every stream
+// push (including no-op heartbeats of the current snapshot) would
+// re-save, which is needlessly chatty against a rulecache.Cache backed by
+// the Kubernetes API.
+func (c *Client) recordAppliedRules(ctx context.Context, rules v1alpha1.VerificationRulesTconfigd, source string, persist bool) {
+	rulesJson, err := json.Marshal(rules)
+
+	var newHash string
+	if err == nil {
+		sum := sha256.Sum256(rulesJson)
+		newHash = hex.EncodeToString(sum[:])
+	}
+
+	c.rulesStatusMu.Lock()
+	changed := err != nil || newHash != c.lastAppliedRulesHash
+	if err != nil {
+		c.logger.Error("Failed to hash applied rules", zap.Error(err))
+	} else {
+		c.lastAppliedRulesHash = newHash
+	}
+	c.rulesSource = source
+	c.rulesAppliedAt = time.Now()
+	c.rulesStatusMu.Unlock()
+
+	if persist && changed {
+		if err := c.ruleCache.Save(ctx, rules); err != nil {
+			c.logger.Error("Failed to persist verification rules to cache", zap.Error(err))
+		}
+	}
+}
+
+// applyCachedRulesIfFresh loads the last-cached rules and applies them if
+// they're within maxRuleStaleness (0 meaning no limit), for use when
+// tconfigd can't be reached. It reports whether cached rules were applied.
+func (c *Client) applyCachedRulesIfFresh(ctx context.Context) bool {
+	cached, err := c.ruleCache.Load(ctx)
+	if err != nil {
+		c.logger.Warn("No cached verification rules available", zap.Error(err))
+
+		return false
+	}
+
+	age := time.Since(cached.CachedAt)
+
+	if c.maxRuleStaleness > 0 && age > c.maxRuleStaleness {
+		c.logger.Error("Cached verification rules are too stale to use", zap.Duration("age", age), zap.Duration("maxAge", c.maxRuleStaleness))
+
+		return false
+	}
+
+	c.applyRules(ctx, cached.Rules, cached.Source, false)
+
+	c.logger.Warn("Applied cached verification rules after failing to reach tconfigd", zap.String("source", cached.Source), zap.Duration("age", age))
+
+	return true
+}
+
+func (c *Client) getLeaseID() string {
+	c.leaseMu.RLock()
+	defer c.leaseMu.RUnlock()
+
+	return c.leaseID
+}
+
+func (c *Client) setNegotiatedSession(leaseID, ruleSchemaVersion string) {
+	c.leaseMu.Lock()
+	defer c.leaseMu.Unlock()
+
+	c.leaseID = leaseID
+	c.negotiatedRuleSchemaVersion = ruleSchemaVersion
+}
+
 type registrationRequest struct {
-	IPAddress string `json:"ipAddress"`
-	Port      int    `json:"port"`
-	Namespace string `json:"namespace"`
+	IPAddress     string      `json:"ipAddress"`
+	Port          int         `json:"port"`
+	Namespace     string      `json:"namespace"`
+	AgentID       string      `json:"agentId"`
+	AgentVersion  string      `json:"agentVersion"`
+	Features      []string    `json:"features"`
+	BootTimestamp int64       `json:"bootTimestamp"`
+	Counter       uint64      `json:"counter"`
+	Health        agentHealth `json:"health"`
 }
 
 type heartBeatRequest struct {
-	IPAddress string `json:"ipAddress"`
-	Port      int    `json:"port"`
-	Namespace string `json:"namespace"`
+	IPAddress     string      `json:"ipAddress"`
+	Port          int         `json:"port"`
+	Namespace     string      `json:"namespace"`
+	AgentID       string      `json:"agentId"`
+	LeaseID       string      `json:"leaseId"`
+	BootTimestamp int64       `json:"bootTimestamp"`
+	Counter       uint64      `json:"counter"`
+	Health        agentHealth `json:"health"`
 }
 
-func (c *Client) Start() error {
-	if err := c.registerWithBackoff(); err != nil {
-		return fmt.Errorf("failed to register with tconfigd: %w", err)
+// Start registers with tconfigd and begins keeping rules and liveness up
+// to date. The supplied ctx governs the client's entire lifetime; cancel
+// it (or call Shutdown) to stop all background work.
+func (c *Client) Start(ctx context.Context) error {
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	if err := c.registerWithBackoff(c.ctx); err != nil {
+		if !c.applyCachedRulesIfFresh(c.ctx) {
+			return fmt.Errorf("failed to register with tconfigd and no usable cached rules: %w", err)
+		}
+
+		c.logger.Warn("Operating on cached verification rules; retrying registration with tconfigd in the background", zap.Error(err))
+
+		go c.registerInBackgroundUntilSuccess()
+
+		return nil
 	}
 
 	c.logger.Info("Successfully registered to tconfigd")
 
-	c.logger.Info("Starting heartbeats to tconfigd...")
+	go c.reresolveTconfigdURL()
+	go c.runRuleStreamOrFallback()
+
+	return nil
+}
+
+// Shutdown stops all background work and tells tconfigd to deregister this
+// agent immediately instead of waiting for it to time out a missed
+// heartbeat.
+func (c *Client) Shutdown(ctx context.Context) error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	deregisterReq := deregistrationRequest{
+		AgentID: c.agentID,
+		LeaseID: c.getLeaseID(),
+	}
+
+	jsonData, err := json.Marshal(deregisterReq)
+	if err != nil {
+		return fmt.Errorf("failed to marshal deregistration data: %w", err)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, c.requestTimeout())
+	defer cancel()
 
-	go c.startHeartbeat()
+	deregisterEndpoint := c.getTconfigdURL().ResolveReference(&url.URL{Path: DEREGISTRATION_PATH})
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, deregisterEndpoint.String(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create deregistration request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.tconfigdMtlsClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send deregistration request: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("deregistration failed with status %d", resp.StatusCode)
+	}
 
 	return nil
 }
 
-func (c *Client) registerWithBackoff() error {
-	var attempt int
+type deregistrationRequest struct {
+	AgentID string `json:"agentId"`
+	LeaseID string `json:"leaseId"`
+}
+
+// requestTimeout derives a per-request timeout from the heartbeat
+// interval so a hung request doesn't silently eat an entire interval;
+// it's capped at maxRequestTimeout for very long heartbeat intervals.
+func (c *Client) requestTimeout() time.Duration {
+	timeout := c.heartbeatInterval / 2
+	if c.heartbeatInterval <= 0 || timeout > maxRequestTimeout {
+		return maxRequestTimeout
+	}
+
+	return timeout
+}
+
+// ctxSleep waits for d or until ctx is done, whichever comes first,
+// so retry loops can be interrupted cleanly instead of blocking in
+// time.Sleep.
+func ctxSleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// registerInBackgroundUntilSuccess keeps retrying registration after
+// Start fell back to cached rules, so the agent returns to live rules as
+// soon as tconfigd becomes reachable again instead of staying on a stale
+// snapshot forever.
+func (c *Client) registerInBackgroundUntilSuccess() {
+	for {
+		if err := c.registerWithBackoff(c.ctx); err == nil {
+			c.logger.Info("Recovered registration with tconfigd after operating on cached rules")
+
+			go c.reresolveTconfigdURL()
+			go c.runRuleStreamOrFallback()
+
+			return
+		} else if c.ctx.Err() != nil {
+			return
+		}
+
+		if ctxSleep(c.ctx, FAILED_HEARTBEAT_RETRY_INTERVAL) != nil {
+			return
+		}
+	}
+}
+
+// reresolveTconfigdURL keeps tconfigdUrl current so rollouts or failovers
+// behind a dynamic discovery backend don't strand the client on a dead
+// address between registrations.
+func (c *Client) reresolveTconfigdURL() {
+	ticker := time.NewTicker(tconfigdResolveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			resolved, err := c.resolver.Resolve(c.ctx)
+			if err != nil {
+				c.logger.Warn("Failed to re-resolve tconfigd address, keeping last known address", zap.Error(err))
+
+				continue
+			}
+
+			c.setTconfigdURL(resolved)
+		}
+	}
+}
+
+func (c *Client) getTconfigdURL() *url.URL {
+	c.tconfigdUrlMu.RLock()
+	defer c.tconfigdUrlMu.RUnlock()
+
+	return c.tconfigdUrl
+}
+
+func (c *Client) setTconfigdURL(u *url.URL) {
+	c.tconfigdUrlMu.Lock()
+	defer c.tconfigdUrlMu.Unlock()
+
+	if c.tconfigdUrl == nil || u.String() != c.tconfigdUrl.String() {
+		c.logger.Info("tconfigd address updated", zap.String("url", u.String()))
+	}
+
+	c.tconfigdUrl = u
+}
+
+func (c *Client) registerWithBackoff(ctx context.Context) error {
+	var (
+		attempt int
+		backoff = registerMinBackoff
+	)
 
 	for {
-		if err := c.register(); err != nil {
+		if err := c.register(ctx); err != nil {
 			c.logger.Error("Registration failed", zap.Error(err))
 
 			attempt++
@@ -90,11 +487,13 @@ func (c *Client) registerWithBackoff() error {
 				return fmt.Errorf("max registration attempts reached: %w", err)
 			}
 
-			backoff := time.Duration(rand.Intn(1<<attempt)) * time.Second
+			backoff = decorrelatedJitterBackoff(registerMinBackoff, registerMaxBackoff, backoff)
 
 			c.logger.Info("Retrying registration", zap.Duration("backoff", backoff), zap.Int("attempt", attempt))
 
-			time.Sleep(backoff)
+			if err := ctxSleep(ctx, backoff); err != nil {
+				return fmt.Errorf("registration cancelled: %w", err)
+			}
 
 			continue
 		}
@@ -106,15 +505,28 @@ func (c *Client) registerWithBackoff() error {
 }
 
 type registrationResponse struct {
-	HeartBeatIntervalMinutes int                                `json:"heartBeatIntervalMinutes"`
-	VerificationRules        v1alpha1.VerificationRulesTconfigd `json:"verificationRules"`
+	HeartBeatIntervalMinutes    int                                `json:"heartBeatIntervalMinutes"`
+	VerificationRules           v1alpha1.VerificationRulesTconfigd `json:"verificationRules"`
+	NegotiatedRuleSchemaVersion string                             `json:"negotiatedRuleSchemaVersion"`
+	LeaseID                     string                             `json:"leaseId"`
 }
 
-func (c *Client) register() error {
+func (c *Client) register(ctx context.Context) error {
+	webhookIP, err := c.selfAdvertiser.Address(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to determine advertise address: %w", err)
+	}
+
 	registrationReq := registrationRequest{
-		IPAddress: c.webhookIP,
-		Port:      c.webhookPort,
-		Namespace: c.namespace,
+		IPAddress:     webhookIP,
+		Port:          c.webhookPort,
+		Namespace:     c.namespace,
+		AgentID:       c.agentID,
+		AgentVersion:  version.Version,
+		Features:      SupportedRuleSchemaVersions,
+		BootTimestamp: c.bootTimestamp,
+		Counter:       atomic.AddUint64(&c.heartbeatCounter, 1),
+		Health:        c.healthSnapshot(),
 	}
 
 	jsonData, err := json.Marshal(registrationReq)
@@ -122,9 +534,12 @@ func (c *Client) register() error {
 		return fmt.Errorf("failed to marshal registration data: %w", err)
 	}
 
-	registerEndpoint := c.tconfigdUrl.ResolveReference(&url.URL{Path: REGISTRATION_PATH})
+	reqCtx, cancel := context.WithTimeout(ctx, c.requestTimeout())
+	defer cancel()
+
+	registerEndpoint := c.getTconfigdURL().ResolveReference(&url.URL{Path: REGISTRATION_PATH})
 
-	req, err := http.NewRequest(http.MethodPost, registerEndpoint.String(), bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, registerEndpoint.String(), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create registration request: %w", err)
 	}
@@ -147,33 +562,63 @@ func (c *Client) register() error {
 		return fmt.Errorf("failed to decode registration response: %w", err)
 	}
 
-	c.verificationRulesManager.UpdateCompleteRules(registrationResp.VerificationRules)
+	c.applyRules(ctx, registrationResp.VerificationRules, "live", true)
+	c.setNegotiatedSession(registrationResp.LeaseID, registrationResp.NegotiatedRuleSchemaVersion)
 
 	return nil
 }
 
-func (c *Client) startHeartbeat() {
-	heartbeatEndpoint := c.tconfigdUrl.ResolveReference(&url.URL{Path: HEARTBEAT_PATH})
-
+func (c *Client) startHeartbeat(ctx context.Context) {
 	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		webhookIP, err := c.selfAdvertiser.Address(ctx)
+		if err != nil {
+			c.logger.Error("Failed to determine advertise address", zap.Error(err))
+
+			if ctxSleep(ctx, FAILED_HEARTBEAT_RETRY_INTERVAL) != nil {
+				return
+			}
+
+			continue
+		}
+
 		heartBeatReq := heartBeatRequest{
-			IPAddress: c.webhookIP,
-			Port:      c.webhookPort,
-			Namespace: c.namespace,
+			IPAddress:     webhookIP,
+			Port:          c.webhookPort,
+			Namespace:     c.namespace,
+			AgentID:       c.agentID,
+			LeaseID:       c.getLeaseID(),
+			BootTimestamp: c.bootTimestamp,
+			Counter:       atomic.AddUint64(&c.heartbeatCounter, 1),
+			Health:        c.healthSnapshot(),
 		}
 
 		heartBeatRequestJson, err := json.Marshal(heartBeatReq)
 		if err != nil {
 			c.logger.Error("Failed to marshal heartbeat request", zap.Error(err))
-			time.Sleep(FAILED_HEARTBEAT_RETRY_INTERVAL)
+
+			if ctxSleep(ctx, FAILED_HEARTBEAT_RETRY_INTERVAL) != nil {
+				return
+			}
 
 			continue
 		}
 
-		req, err := http.NewRequest(http.MethodPost, heartbeatEndpoint.String(), bytes.NewBuffer(heartBeatRequestJson))
+		heartbeatEndpoint := c.getTconfigdURL().ResolveReference(&url.URL{Path: HEARTBEAT_PATH})
+
+		reqCtx, cancel := context.WithTimeout(ctx, c.requestTimeout())
+
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, heartbeatEndpoint.String(), bytes.NewBuffer(heartBeatRequestJson))
 		if err != nil {
+			cancel()
 			c.logger.Error("Failed to create heartbeat request", zap.Error(err))
-			time.Sleep(FAILED_HEARTBEAT_RETRY_INTERVAL)
+
+			if ctxSleep(ctx, FAILED_HEARTBEAT_RETRY_INTERVAL) != nil {
+				return
+			}
 
 			continue
 		}
@@ -181,9 +626,15 @@ func (c *Client) startHeartbeat() {
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, err := c.tconfigdMtlsClient.Do(req)
+
+		cancel()
+
 		if err != nil {
 			c.logger.Error("Failed to send heartbeat", zap.Error(err))
-			time.Sleep(FAILED_HEARTBEAT_RETRY_INTERVAL)
+
+			if ctxSleep(ctx, FAILED_HEARTBEAT_RETRY_INTERVAL) != nil {
+				return
+			}
 
 			continue
 		} else {
@@ -191,7 +642,10 @@ func (c *Client) startHeartbeat() {
 
 			if resp.StatusCode != http.StatusOK {
 				c.logger.Error("Received non-ok heartbeat response", zap.Int("status", resp.StatusCode))
-				time.Sleep(FAILED_HEARTBEAT_RETRY_INTERVAL)
+
+				if ctxSleep(ctx, FAILED_HEARTBEAT_RETRY_INTERVAL) != nil {
+					return
+				}
 
 				continue
 			} else {
@@ -199,23 +653,8 @@ func (c *Client) startHeartbeat() {
 			}
 		}
 
-		time.Sleep(c.heartbeatInterval)
-	}
-}
-
-func getLocalIP() (string, error) {
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
-		return "", err
-	}
-
-	for _, addr := range addrs {
-		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
-			if ipNet.IP.To4() != nil {
-				return ipNet.IP.String(), nil
-			}
+		if ctxSleep(ctx, c.heartbeatInterval) != nil {
+			return
 		}
 	}
-
-	return "", fmt.Errorf("couldn't obtain a webhook IP address")
 }
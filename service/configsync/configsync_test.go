@@ -0,0 +1,163 @@
+package configsync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tratteria/tratteria-agent/service/rulecache"
+	"github.com/tratteria/tratteria-agent/verificationrules/v1alpha1"
+	"go.uber.org/zap"
+)
+
+// fakeRulesManager is a minimal v1alpha1.VerificationRulesManager for
+// exercising Client's rule-apply paths without a real tratteria
+// interceptor.
+type fakeRulesManager struct {
+	completeCalls int
+	deltaCalls    int
+	lastUpserts   map[string]v1alpha1.TraTVerificationRule
+	lastDeletes   []string
+}
+
+func (f *fakeRulesManager) UpdateCompleteRules(rules v1alpha1.VerificationRulesTconfigd) {
+	f.completeCalls++
+}
+
+func (f *fakeRulesManager) ApplyRuleDelta(upserts map[string]v1alpha1.TraTVerificationRule, deletes []string) {
+	f.deltaCalls++
+	f.lastUpserts = upserts
+	f.lastDeletes = deletes
+}
+
+func newTestClient() (*Client, *fakeRulesManager) {
+	manager := &fakeRulesManager{}
+
+	return &Client{
+		verificationRulesManager: manager,
+		ruleCache:                rulecache.NewInMemoryCache(),
+		logger:                   zap.NewNop(),
+	}, manager
+}
+
+func ruleSet(names ...string) v1alpha1.VerificationRulesTconfigd {
+	trats := make(map[string]v1alpha1.TraTVerificationRule, len(names))
+	for _, name := range names {
+		trats[name] = v1alpha1.TraTVerificationRule{Name: name}
+	}
+
+	return v1alpha1.VerificationRulesTconfigd{TraTs: trats}
+}
+
+func TestApplyRulesPersistsOnlyWhenRulesChange(t *testing.T) {
+	client, manager := newTestClient()
+	ctx := context.Background()
+
+	client.applyRules(ctx, ruleSet("a", "b"), "live", true)
+
+	if manager.completeCalls != 1 {
+		t.Fatalf("expected 1 UpdateCompleteRules call, got %d", manager.completeCalls)
+	}
+
+	cached, err := client.ruleCache.Load(ctx)
+	if err != nil {
+		t.Fatalf("expected rules to be cached after first apply: %v", err)
+	}
+
+	firstCachedAt := cached.CachedAt
+
+	// Re-applying the identical rule set must not rewrite the cache.
+	client.applyRules(ctx, ruleSet("a", "b"), "live", true)
+
+	cached, err = client.ruleCache.Load(ctx)
+	if err != nil {
+		t.Fatalf("expected rules still cached: %v", err)
+	}
+
+	if !cached.CachedAt.Equal(firstCachedAt) {
+		t.Fatalf("expected cache not to be rewritten for an unchanged rule set, cachedAt changed from %v to %v", firstCachedAt, cached.CachedAt)
+	}
+
+	// A genuinely different rule set must persist again.
+	client.applyRules(ctx, ruleSet("a", "b", "c"), "live", true)
+
+	cached, err = client.ruleCache.Load(ctx)
+	if err != nil {
+		t.Fatalf("expected rules still cached: %v", err)
+	}
+
+	if cached.CachedAt.Equal(firstCachedAt) {
+		t.Fatalf("expected cache to be rewritten after the rule set changed")
+	}
+}
+
+func TestApplyRuleDeltaMergesOntoCurrentRules(t *testing.T) {
+	client, manager := newTestClient()
+	ctx := context.Background()
+
+	client.applyRules(ctx, ruleSet("a", "b"), "live", true)
+
+	client.applyRuleDelta(ctx, map[string]v1alpha1.TraTVerificationRule{
+		"c": {Name: "c"},
+	}, []string{"a"}, "live", true)
+
+	if manager.deltaCalls != 1 {
+		t.Fatalf("expected 1 ApplyRuleDelta call, got %d", manager.deltaCalls)
+	}
+
+	if _, ok := manager.lastUpserts["c"]; !ok {
+		t.Fatalf("expected delta upserts to reach the manager")
+	}
+
+	client.currentRulesMu.Lock()
+	_, hasA := client.currentRules.TraTs["a"]
+	_, hasB := client.currentRules.TraTs["b"]
+	_, hasC := client.currentRules.TraTs["c"]
+	client.currentRulesMu.Unlock()
+
+	if hasA {
+		t.Fatalf("expected deleted TraT %q to be removed from the merged rule set", "a")
+	}
+
+	if !hasB || !hasC {
+		t.Fatalf("expected merged rule set to retain %q and gain %q", "b", "c")
+	}
+}
+
+func TestApplyCachedRulesIfFreshRespectsMaxStaleness(t *testing.T) {
+	client, _ := newTestClient()
+	ctx := context.Background()
+
+	if err := client.ruleCache.Save(ctx, ruleSet("a")); err != nil {
+		t.Fatalf("failed to seed rule cache: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	client.maxRuleStaleness = time.Millisecond
+
+	if client.applyCachedRulesIfFresh(ctx) {
+		t.Fatalf("expected stale cached rules to be rejected")
+	}
+
+	client.maxRuleStaleness = time.Hour
+
+	if !client.applyCachedRulesIfFresh(ctx) {
+		t.Fatalf("expected fresh cached rules to be applied")
+	}
+}
+
+func TestApplyCachedRulesIfFreshWithNoMaxStalenessAcceptsAnyAge(t *testing.T) {
+	client, _ := newTestClient()
+	ctx := context.Background()
+
+	if err := client.ruleCache.Save(ctx, ruleSet("a")); err != nil {
+		t.Fatalf("failed to seed rule cache: %v", err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if !client.applyCachedRulesIfFresh(ctx) {
+		t.Fatalf("expected maxRuleStaleness == 0 to mean unlimited age")
+	}
+}
@@ -0,0 +1,51 @@
+package configsync
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const defaultAgentIDPath = "/var/run/tratteria-agent/agent-id"
+
+// loadOrCreateAgentID returns a stable identifier for this agent instance,
+// persisted to disk so it survives process restarts; a fresh ID on every
+// restart would look like a new agent to tconfigd and defeat flapping
+// detection. Once the agent's own SPIFFE ID is plumbed through to this
+// package, that should be preferred over a generated one since it's
+// already a stable, globally unique identity.
+func loadOrCreateAgentID(path string) (string, error) {
+	if path == "" {
+		path = defaultAgentIDPath
+	}
+
+	if existing, err := os.ReadFile(path); err == nil && len(existing) > 0 {
+		return string(existing), nil
+	}
+
+	id, err := generateAgentID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate agent id: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create agent id directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte(id), 0o644); err != nil {
+		return "", fmt.Errorf("failed to persist agent id: %w", err)
+	}
+
+	return id, nil
+}
+
+func generateAgentID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,33 @@
+package configsync
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// StatusPath is the path RegisterStatusHandler mounts the status endpoint
+// on; the agent's HTTP API server should serve it on AgentHttpApiPort (or
+// AgentHttpsApiPort) alongside its other routes.
+const StatusPath = "/status"
+
+// RegisterStatusHandler registers a JSON endpoint reporting Status (the
+// agent's current rules source and cache age) onto mux, so operators can
+// tell whether this agent is running on live or cached rules without
+// extra telemetry plumbing.
+func (c *Client) RegisterStatusHandler(mux *http.ServeMux) {
+	mux.HandleFunc(StatusPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(c.Status()); err != nil {
+			c.logger.Error("Failed to encode status response", zap.Error(err))
+		}
+	})
+}
@@ -0,0 +1,318 @@
+package configsync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/tratteria/tratteria-agent/verificationrules/v1alpha1"
+	"go.uber.org/zap"
+)
+
+const (
+	RULES_STREAM_PATH = "rules/stream"
+
+	streamHeartbeatInterval = 15 * time.Second
+	streamMinBackoff        = 500 * time.Millisecond
+	streamMaxBackoff        = 30 * time.Second
+)
+
+// errStreamUnsupported signals that tconfigd doesn't expose the rules
+// streaming endpoint (older tconfigd versions), so the caller should fall
+// back to the register/heartbeat REST path instead of retrying the stream.
+var errStreamUnsupported = errors.New("tconfigd does not support rule streaming")
+
+// ruleDeltaOp identifies the kind of update carried by a single line of the
+// rules stream.
+type ruleDeltaOp string
+
+const (
+	ruleDeltaOpSnapshot ruleDeltaOp = "snapshot"
+	ruleDeltaOpDelta    ruleDeltaOp = "delta"
+)
+
+// ruleStreamMessage is one newline-delimited JSON message pushed by tconfigd
+// over the rules stream. A "snapshot" carries the complete rule set in
+// Rules.TraTs and is sent on connect or whenever tconfigd can't reconcile
+// from ResourceVersion. A "delta" carries only the TraTs that were added
+// or changed in Rules.TraTs, plus the names of any TraTs that were
+// removed in DeletedTraTs; the client merges it onto its locally-tracked
+// rule set via applyRuleDelta rather than replacing the whole set.
+type ruleStreamMessage struct {
+	Op              ruleDeltaOp                        `json:"op"`
+	ResourceVersion string                             `json:"resourceVersion"`
+	Rules           v1alpha1.VerificationRulesTconfigd `json:"rules"`
+	DeletedTraTs    []string                           `json:"deletedTrats,omitempty"`
+}
+
+// ruleStreamHeartbeat is written periodically onto the request body of the
+// open stream, multiplexing the liveness heartbeat that used to be a
+// separate POST loop onto the same long-lived connection. It carries the
+// same identity, lease and health fields as heartBeatRequest so tconfigd's
+// flapping detection and lease tracking work the same way whether an agent
+// is on the stream or the REST fallback.
+type ruleStreamHeartbeat struct {
+	IPAddress       string      `json:"ipAddress"`
+	Port            int         `json:"port"`
+	Namespace       string      `json:"namespace"`
+	ResourceVersion string      `json:"resourceVersion"`
+	AgentID         string      `json:"agentId"`
+	LeaseID         string      `json:"leaseId"`
+	BootTimestamp   int64       `json:"bootTimestamp"`
+	Counter         uint64      `json:"counter"`
+	Health          agentHealth `json:"health"`
+}
+
+// runRuleStreamOrFallback tries to keep rules up to date via the
+// server-push stream, and falls back to the legacy heartbeat-polling loop
+// if tconfigd doesn't support it.
+func (c *Client) runRuleStreamOrFallback() {
+	if err := c.streamRules(); err != nil {
+		c.logger.Info("tconfigd does not support rule streaming, falling back to heartbeat polling", zap.Error(err))
+		c.logger.Info("Starting heartbeats to tconfigd...")
+
+		c.startHeartbeat(c.ctx)
+	}
+}
+
+// streamRules keeps a rules stream connection open, reconnecting with
+// jittered backoff, until c.ctx is cancelled. It returns
+// errStreamUnsupported if tconfigd doesn't expose the streaming endpoint,
+// so the caller can fall back to the register/heartbeat REST path.
+func (c *Client) streamRules() error {
+	var (
+		attempt     int
+		prevBackoff = streamMinBackoff
+	)
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		default:
+		}
+
+		streamErr := c.runStreamOnce()
+		if streamErr == nil {
+			return nil
+		}
+
+		if errors.Is(streamErr, errStreamUnsupported) {
+			return streamErr
+		}
+
+		c.logger.Warn("Rule stream disconnected, reconnecting", zap.Error(streamErr), zap.Int("attempt", attempt))
+
+		attempt++
+		prevBackoff = decorrelatedJitterBackoff(streamMinBackoff, streamMaxBackoff, prevBackoff)
+
+		select {
+		case <-time.After(prevBackoff):
+		case <-c.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// runStreamOnce opens a single rules-stream connection and blocks until it
+// errors out or c.ctx is cancelled. The request body is a
+// newline-delimited stream of heartbeats written by this client; the
+// response body is a newline-delimited stream of ruleStreamMessage written
+// by tconfigd, giving heartbeats and rule pushes a single long-lived
+// connection instead of two independent polling loops.
+func (c *Client) runStreamOnce() error {
+	pipeReader, pipeWriter := io.Pipe()
+
+	streamEndpoint := c.getTconfigdURL().ResolveReference(&url.URL{Path: RULES_STREAM_PATH})
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, streamEndpoint.String(), pipeReader)
+	if err != nil {
+		pipeWriter.Close()
+
+		return fmt.Errorf("failed to create rules stream request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	heartbeatDone := make(chan struct{})
+
+	go c.writeStreamHeartbeats(pipeWriter, heartbeatDone)
+
+	resp, err := c.tconfigdMtlsClient.Do(req)
+	if err != nil {
+		close(heartbeatDone)
+		pipeWriter.Close()
+
+		return fmt.Errorf("failed to open rules stream: %w", err)
+	}
+
+	defer func() {
+		close(heartbeatDone)
+		pipeWriter.Close()
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNotImplemented {
+		return errStreamUnsupported
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rules stream failed with status %d", resp.StatusCode)
+	}
+
+	c.logger.Info("Rules stream connected", zap.String("resourceVersion", c.getResourceVersion()))
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg ruleStreamMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			atomic.AddUint64(&c.ruleDecodeErrors, 1)
+			c.logger.Error("Failed to decode rules stream message", zap.Error(err))
+
+			continue
+		}
+
+		if err := c.applyRuleStreamMessage(msg); err != nil {
+			return err
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return nil
+		default:
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("rules stream read error: %w", err)
+	}
+
+	return fmt.Errorf("rules stream closed by tconfigd")
+}
+
+// applyRuleStreamMessage reconciles one pushed message into the
+// verification rules manager: a snapshot replaces the whole rule set via
+// applyRules, a delta merges Rules.TraTs (upserts) and DeletedTraTs
+// (removals) onto the client's locally-tracked rule set via
+// applyRuleDelta. An unknown op forces a full resync rather than silently
+// dropping rules the client doesn't know how to apply.
+func (c *Client) applyRuleStreamMessage(msg ruleStreamMessage) error {
+	switch msg.Op {
+	case ruleDeltaOpSnapshot:
+		c.applyRules(c.ctx, msg.Rules, "live", true)
+	case ruleDeltaOpDelta:
+		c.applyRuleDelta(c.ctx, msg.Rules.TraTs, msg.DeletedTraTs, "live", true)
+	default:
+		c.setResourceVersion("")
+
+		return fmt.Errorf("received rule stream message with unknown op %q, forcing full resync", msg.Op)
+	}
+
+	c.setResourceVersion(msg.ResourceVersion)
+
+	c.logger.Info("Applied rules from stream", zap.String("op", string(msg.Op)), zap.String("resourceVersion", msg.ResourceVersion), zap.Int("upserts", len(msg.Rules.TraTs)), zap.Int("deletes", len(msg.DeletedTraTs)))
+
+	return nil
+}
+
+func (c *Client) writeStreamHeartbeats(w io.WriteCloser, done <-chan struct{}) {
+	ticker := time.NewTicker(streamHeartbeatInterval)
+	defer ticker.Stop()
+
+	write := func() bool {
+		webhookIP, err := c.selfAdvertiser.Address(c.ctx)
+		if err != nil {
+			c.logger.Error("Failed to determine advertise address for stream heartbeat", zap.Error(err))
+
+			return true
+		}
+
+		hb := ruleStreamHeartbeat{
+			IPAddress:       webhookIP,
+			Port:            c.webhookPort,
+			Namespace:       c.namespace,
+			ResourceVersion: c.getResourceVersion(),
+			AgentID:         c.agentID,
+			LeaseID:         c.getLeaseID(),
+			BootTimestamp:   c.bootTimestamp,
+			Counter:         atomic.AddUint64(&c.heartbeatCounter, 1),
+			Health:          c.healthSnapshot(),
+		}
+
+		hbJson, err := json.Marshal(hb)
+		if err != nil {
+			c.logger.Error("Failed to marshal stream heartbeat", zap.Error(err))
+
+			return true
+		}
+
+		if _, err := w.Write(append(hbJson, '\n')); err != nil {
+			return false
+		}
+
+		return true
+	}
+
+	if !write() {
+		return
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !write() {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) getResourceVersion() string {
+	c.resourceVersionMu.Lock()
+	defer c.resourceVersionMu.Unlock()
+
+	return c.resourceVersion
+}
+
+func (c *Client) setResourceVersion(v string) {
+	c.resourceVersionMu.Lock()
+	defer c.resourceVersionMu.Unlock()
+
+	c.resourceVersion = v
+}
+
+// decorrelatedJitterBackoff picks the next backoff using the "decorrelated
+// jitter" formula (sleep = min(cap, random_between(base, prev*3))), which
+// spreads retries out more evenly across a fleet than naive exponential
+// backoff.
+func decorrelatedJitterBackoff(base, cap, prev time.Duration) time.Duration {
+	upper := prev * 3
+	if upper < base {
+		upper = base
+	}
+
+	next := base + time.Duration(rand.Int63n(int64(upper-base+1)))
+	if next > cap {
+		next = cap
+	}
+
+	return next
+}
@@ -0,0 +1,50 @@
+package configsync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecorrelatedJitterBackoffStaysWithinBaseAndCap(t *testing.T) {
+	base := 1 * time.Second
+	maxBackoff := 10 * time.Second
+
+	prev := base
+	for i := 0; i < 200; i++ {
+		next := decorrelatedJitterBackoff(base, maxBackoff, prev)
+
+		if next < base || next > maxBackoff {
+			t.Fatalf("backoff %v out of bounds [%v, %v] for prev %v", next, base, maxBackoff, prev)
+		}
+
+		prev = next
+	}
+}
+
+func TestDecorrelatedJitterBackoffClampsToCap(t *testing.T) {
+	// With base == cap, every candidate (which is always >= base) that
+	// lands above base must get clamped back down to cap, so the result
+	// is cap regardless of the random draw.
+	baseAndCap := 10 * time.Second
+
+	for i := 0; i < 50; i++ {
+		next := decorrelatedJitterBackoff(baseAndCap, baseAndCap, baseAndCap*10)
+
+		if next != baseAndCap {
+			t.Fatalf("expected backoff to clamp to cap %v when prev greatly exceeds it, got %v", baseAndCap, next)
+		}
+	}
+}
+
+func TestDecorrelatedJitterBackoffHandlesZeroPrev(t *testing.T) {
+	base := 500 * time.Millisecond
+	maxBackoff := 30 * time.Second
+
+	for i := 0; i < 50; i++ {
+		next := decorrelatedJitterBackoff(base, maxBackoff, 0)
+
+		if next < base || next > maxBackoff {
+			t.Fatalf("backoff %v out of bounds [%v, %v] for a zero prev", next, base, maxBackoff)
+		}
+	}
+}
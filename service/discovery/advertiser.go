@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	AdvertiseModePodIP             = "pod-ip"
+	AdvertiseModeFirstNonLoopback  = "first-non-loopback"
+	AdvertiseModeStatic            = "static"
+	AdvertiseModeKubernetesService = "kubernetes"
+)
+
+// SelfAdvertiser determines the address this agent advertises to tconfigd
+// on registration and on every heartbeat.
+type SelfAdvertiser interface {
+	Address(ctx context.Context) (string, error)
+}
+
+// PodIPAdvertiser reads the pod IP from an environment variable populated
+// by the Kubernetes downward API (fieldRef: status.podIP), the only
+// address guaranteed routable when the pod or host has multiple NICs or
+// IPv6-only interfaces.
+type PodIPAdvertiser struct {
+	envVar string
+}
+
+func NewPodIPAdvertiser(envVar string) *PodIPAdvertiser {
+	if envVar == "" {
+		envVar = "POD_IP"
+	}
+
+	return &PodIPAdvertiser{envVar: envVar}
+}
+
+func (a *PodIPAdvertiser) Address(ctx context.Context) (string, error) {
+	ip, exists := os.LookupEnv(a.envVar)
+	if !exists || ip == "" {
+		return "", fmt.Errorf("%s environment variable not set; add a downward API fieldRef for status.podIP", a.envVar)
+	}
+
+	return ip, nil
+}
+
+// FirstNonLoopbackAdvertiser replicates the agent's original behaviour of
+// scanning local interfaces for the first non-loopback IPv4 address.
+type FirstNonLoopbackAdvertiser struct{}
+
+func NewFirstNonLoopbackAdvertiser() *FirstNonLoopbackAdvertiser {
+	return &FirstNonLoopbackAdvertiser{}
+}
+
+func (a *FirstNonLoopbackAdvertiser) Address(ctx context.Context) (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+			if ipNet.IP.To4() != nil {
+				return ipNet.IP.String(), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("couldn't obtain a webhook IP address")
+}
+
+// StaticAdvertiser always returns an operator-supplied address, for
+// environments where neither interface scanning nor the downward API give
+// a routable result (e.g. host networking behind NAT).
+type StaticAdvertiser struct {
+	address string
+}
+
+func NewStaticAdvertiser(address string) *StaticAdvertiser {
+	return &StaticAdvertiser{address: address}
+}
+
+func (a *StaticAdvertiser) Address(ctx context.Context) (string, error) {
+	if a.address == "" {
+		return "", fmt.Errorf("static advertise address not configured")
+	}
+
+	return a.address, nil
+}
+
+// KubernetesServiceAdvertiser looks up the pod's own status through the
+// Kubernetes API using the pod name and namespace, for agents that already
+// have in-cluster API access but aren't wired up with a POD_IP downward
+// API env var.
+type KubernetesServiceAdvertiser struct {
+	clientset kubernetes.Interface
+	namespace string
+	podName   string
+}
+
+func NewKubernetesServiceAdvertiser(clientset kubernetes.Interface, namespace, podName string) *KubernetesServiceAdvertiser {
+	return &KubernetesServiceAdvertiser{clientset: clientset, namespace: namespace, podName: podName}
+}
+
+func (a *KubernetesServiceAdvertiser) Address(ctx context.Context) (string, error) {
+	pod, err := a.clientset.CoreV1().Pods(a.namespace).Get(ctx, a.podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod %s/%s: %w", a.namespace, a.podName, err)
+	}
+
+	if pod.Status.PodIP == "" {
+		return "", fmt.Errorf("pod %s/%s has no assigned IP yet", a.namespace, a.podName)
+	}
+
+	return pod.Status.PodIP, nil
+}
+
+// NewSelfAdvertiser builds a SelfAdvertiser for the given advertise mode.
+// The Kubernetes mode reads its pod/namespace coordinates from env vars
+// itself, so ADVERTISE_MODE=kubernetes alone is enough to select it.
+func NewSelfAdvertiser(mode, staticAddress string) (SelfAdvertiser, error) {
+	switch mode {
+	case "", AdvertiseModeFirstNonLoopback:
+		return NewFirstNonLoopbackAdvertiser(), nil
+	case AdvertiseModePodIP:
+		return NewPodIPAdvertiser(""), nil
+	case AdvertiseModeStatic:
+		return NewStaticAdvertiser(staticAddress), nil
+	case AdvertiseModeKubernetesService:
+		return newKubernetesServiceAdvertiserFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown advertise mode %q", mode)
+	}
+}
+
+// newKubernetesServiceAdvertiserFromEnv builds a KubernetesServiceAdvertiser
+// from an in-cluster clientset and the MY_NAMESPACE/POD_NAME env vars
+// (POD_NAME populated via the downward API, same pattern as POD_IP above).
+func newKubernetesServiceAdvertiserFromEnv() (*KubernetesServiceAdvertiser, error) {
+	clientset, err := inClusterClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := requireEnv("MY_NAMESPACE")
+	if err != nil {
+		return nil, err
+	}
+
+	podName, err := requireEnv("POD_NAME")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKubernetesServiceAdvertiser(clientset, namespace, podName), nil
+}
@@ -0,0 +1,62 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// ConsulResolver resolves tconfigd's address via the Consul service
+// catalog, considering only instances currently passing their health
+// checks.
+type ConsulResolver struct {
+	client  *consulapi.Client
+	service string
+	scheme  string
+}
+
+func NewConsulResolver(client *consulapi.Client, service, scheme string) *ConsulResolver {
+	return &ConsulResolver{client: client, service: service, scheme: scheme}
+}
+
+// newConsulResolverFromEnv builds a ConsulResolver from TCONFIGD_CONSUL_SERVICE
+// and the standard CONSUL_HTTP_ADDR/CONSUL_HTTP_TOKEN env vars that
+// consulapi.DefaultConfig already understands, so DISCOVERY_MODE=consul
+// needs no discovery-specific client wiring beyond the service name.
+func newConsulResolverFromEnv() (*ConsulResolver, error) {
+	service, err := requireEnv("TCONFIGD_CONSUL_SERVICE")
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := optionalEnv("TCONFIGD_CONSUL_SCHEME", "https")
+
+	client, err := consulapi.NewClient(consulapi.DefaultConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build consul client: %w", err)
+	}
+
+	return NewConsulResolver(client, service, scheme), nil
+}
+
+func (r *ConsulResolver) Resolve(ctx context.Context) (*url.URL, error) {
+	entries, _, err := r.client.Health().Service(r.service, "", true, (&consulapi.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query consul catalog for %s: %w", r.service, err)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no healthy consul instances found for %s", r.service)
+	}
+
+	entry := entries[0]
+
+	addr := entry.Service.Address
+	if addr == "" {
+		addr = entry.Node.Address
+	}
+
+	return &url.URL{Scheme: r.scheme, Host: fmt.Sprintf("%s:%d", addr, entry.Service.Port)}, nil
+}
@@ -0,0 +1,86 @@
+// Package discovery provides pluggable backends for locating tconfigd and
+// for determining the address this agent should advertise about itself,
+// so neither is hard-coded to a single env var or interface scan.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+const (
+	ModeStatic     = "static"
+	ModeDNS        = "dns"
+	ModeKubernetes = "kubernetes"
+	ModeConsul     = "consul"
+)
+
+// Resolver resolves the current address of tconfigd. Implementations may
+// re-resolve on every call (DNS, Kubernetes, Consul) or simply return a
+// fixed value (Static); configsync.Client re-polls on an interval without
+// needing to know which backend is in use.
+type Resolver interface {
+	Resolve(ctx context.Context) (*url.URL, error)
+}
+
+// StaticResolver always returns the same, pre-parsed tconfigd URL. It's the
+// discovery mode equivalent of the original hard-coded TCONFIGD_URL.
+type StaticResolver struct {
+	url *url.URL
+}
+
+func NewStaticResolver(u *url.URL) *StaticResolver {
+	return &StaticResolver{url: u}
+}
+
+func (r *StaticResolver) Resolve(ctx context.Context) (*url.URL, error) {
+	return r.url, nil
+}
+
+// NewResolver builds a Resolver for the given discovery mode. DNS,
+// Kubernetes and Consul backends read their mode-specific settings (SRV
+// name, in-cluster service coordinates, consul service name, ...) from
+// env vars themselves, so DISCOVERY_MODE alone is enough to switch
+// backends without separate wiring code per mode.
+func NewResolver(mode string, static *url.URL) (Resolver, error) {
+	switch mode {
+	case "", ModeStatic:
+		if static == nil {
+			return nil, fmt.Errorf("static discovery mode requires TCONFIGD_URL to be set")
+		}
+
+		return NewStaticResolver(static), nil
+	case ModeDNS:
+		return newDNSResolverFromEnv()
+	case ModeKubernetes:
+		return newKubernetesResolverFromEnv()
+	case ModeConsul:
+		return newConsulResolverFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown discovery mode %q", mode)
+	}
+}
+
+// requireEnv and optionalEnv back the mode-specific *FromEnv constructors
+// in this package; they mirror service/config's getEnv/getOptionalEnv but return an
+// error instead of panicking, since a bad discovery backend shouldn't take
+// down config loading for unrelated settings.
+func requireEnv(key string) (string, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return "", fmt.Errorf("%s environment variable not set", key)
+	}
+
+	return value, nil
+}
+
+func optionalEnv(key, fallback string) string {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback
+	}
+
+	return value
+}
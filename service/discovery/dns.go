@@ -0,0 +1,57 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DNSResolver resolves tconfigd's address via a DNS SRV record, the
+// pattern most service meshes already use for control-plane discovery
+// (Consul DNS, Kubernetes headless services).
+type DNSResolver struct {
+	scheme  string
+	service string
+	proto   string
+	name    string
+}
+
+func NewDNSResolver(scheme, service, proto, name string) *DNSResolver {
+	return &DNSResolver{scheme: scheme, service: service, proto: proto, name: name}
+}
+
+// newDNSResolverFromEnv builds a DNSResolver from TCONFIGD_DNS_NAME (the
+// SRV name to look up, required) and the usual service/proto/scheme
+// defaults for a tconfigd control-plane SRV record.
+func newDNSResolverFromEnv() (*DNSResolver, error) {
+	name, err := requireEnv("TCONFIGD_DNS_NAME")
+	if err != nil {
+		return nil, err
+	}
+
+	service := optionalEnv("TCONFIGD_DNS_SERVICE", "tconfigd")
+	proto := optionalEnv("TCONFIGD_DNS_PROTO", "tcp")
+	scheme := optionalEnv("TCONFIGD_DNS_SCHEME", "https")
+
+	return NewDNSResolver(scheme, service, proto, name), nil
+}
+
+func (r *DNSResolver) Resolve(ctx context.Context) (*url.URL, error) {
+	_, addrs, err := net.DefaultResolver.LookupSRV(ctx, r.service, r.proto, r.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve SRV record for %s: %w", r.name, err)
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no SRV records found for %s", r.name)
+	}
+
+	target := addrs[0]
+
+	return &url.URL{
+		Scheme: r.scheme,
+		Host:   fmt.Sprintf("%s:%d", strings.TrimSuffix(target.Target, "."), target.Port),
+	}, nil
+}
@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// KubernetesResolver resolves tconfigd's address from its EndpointSlice,
+// falling back to the legacy Endpoints object for older clusters, and
+// picks a ready address so tconfigd rollouts don't strand agents on a
+// terminated pod IP.
+type KubernetesResolver struct {
+	clientset kubernetes.Interface
+	namespace string
+	service   string
+	scheme    string
+	port      int
+}
+
+func NewKubernetesResolver(clientset kubernetes.Interface, namespace, service, scheme string, port int) *KubernetesResolver {
+	return &KubernetesResolver{clientset: clientset, namespace: namespace, service: service, scheme: scheme, port: port}
+}
+
+// newKubernetesResolverFromEnv builds a KubernetesResolver from an
+// in-cluster clientset and TCONFIGD_K8S_NAMESPACE/TCONFIGD_K8S_SERVICE/
+// TCONFIGD_K8S_PORT, so DISCOVERY_MODE=kubernetes works from a pod's own
+// service account without extra wiring code.
+func newKubernetesResolverFromEnv() (*KubernetesResolver, error) {
+	clientset, err := inClusterClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := requireEnv("TCONFIGD_K8S_NAMESPACE")
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := requireEnv("TCONFIGD_K8S_SERVICE")
+	if err != nil {
+		return nil, err
+	}
+
+	portStr, err := requireEnv("TCONFIGD_K8S_PORT")
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TCONFIGD_K8S_PORT %q: %w", portStr, err)
+	}
+
+	scheme := optionalEnv("TCONFIGD_K8S_SCHEME", "https")
+
+	return NewKubernetesResolver(clientset, namespace, service, scheme, port), nil
+}
+
+// inClusterClientset builds a Kubernetes clientset from the pod's own
+// service account, shared by every discovery backend that needs API
+// access (KubernetesResolver and KubernetesServiceAdvertiser).
+func inClusterClientset() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster kubernetes config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kubernetes clientset: %w", err)
+	}
+
+	return clientset, nil
+}
+
+func (r *KubernetesResolver) Resolve(ctx context.Context) (*url.URL, error) {
+	if addr, err := r.resolveFromEndpointSlices(ctx); err == nil {
+		return addr, nil
+	}
+
+	return r.resolveFromEndpoints(ctx)
+}
+
+func (r *KubernetesResolver) resolveFromEndpointSlices(ctx context.Context) (*url.URL, error) {
+	slices, err := r.clientset.DiscoveryV1().EndpointSlices(r.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("kubernetes.io/service-name=%s", r.service),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list endpointslices for %s: %w", r.service, err)
+	}
+
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+
+			for _, addr := range endpoint.Addresses {
+				return &url.URL{Scheme: r.scheme, Host: fmt.Sprintf("%s:%d", addr, r.port)}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no ready endpoints found in endpointslices for %s", r.service)
+}
+
+func (r *KubernetesResolver) resolveFromEndpoints(ctx context.Context) (*url.URL, error) {
+	endpoints, err := r.clientset.CoreV1().Endpoints(r.namespace).Get(ctx, r.service, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoints for %s: %w", r.service, err)
+	}
+
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			return &url.URL{Scheme: r.scheme, Host: fmt.Sprintf("%s:%d", addr.IP, r.port)}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no ready addresses found in endpoints for %s", r.service)
+}
@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubernetesResolverPrefersReadyEndpointSlice(t *testing.T) {
+	ready := true
+
+	clientset := fake.NewSimpleClientset(&discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tconfigd-abc123",
+			Namespace: "tratteria",
+			Labels:    map[string]string{"kubernetes.io/service-name": "tconfigd"},
+		},
+		Endpoints: []discoveryv1.Endpoint{
+			{
+				Addresses:  []string{"10.0.0.5"},
+				Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			},
+		},
+	})
+
+	resolver := NewKubernetesResolver(clientset, "tratteria", "tconfigd", "https", 8443)
+
+	addr, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := addr.String(), "https://10.0.0.5:8443"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestKubernetesResolverFallsBackToEndpointsWhenNoEndpointSlices(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tconfigd",
+			Namespace: "tratteria",
+		},
+		Subsets: []corev1.EndpointSubset{
+			{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.9"}}},
+		},
+	})
+
+	resolver := NewKubernetesResolver(clientset, "tratteria", "tconfigd", "https", 8443)
+
+	addr, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := addr.String(), "https://10.0.0.9:8443"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestKubernetesResolverFallsBackWhenEndpointSliceHasNoReadyEndpoints(t *testing.T) {
+	notReady := false
+
+	clientset := fake.NewSimpleClientset(
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "tconfigd-abc123",
+				Namespace: "tratteria",
+				Labels:    map[string]string{"kubernetes.io/service-name": "tconfigd"},
+			},
+			Endpoints: []discoveryv1.Endpoint{
+				{
+					Addresses:  []string{"10.0.0.5"},
+					Conditions: discoveryv1.EndpointConditions{Ready: &notReady},
+				},
+			},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "tconfigd",
+				Namespace: "tratteria",
+			},
+			Subsets: []corev1.EndpointSubset{
+				{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.9"}}},
+			},
+		},
+	)
+
+	resolver := NewKubernetesResolver(clientset, "tratteria", "tconfigd", "https", 8443)
+
+	addr, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := addr.String(), "https://10.0.0.9:8443"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
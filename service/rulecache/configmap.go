@@ -0,0 +1,102 @@
+package rulecache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/tratteria/tratteria-agent/verificationrules/v1alpha1"
+)
+
+const rulesDataKey = "rules.json"
+
+// ConfigMapCache persists rules in a Kubernetes ConfigMap, for agents
+// running without a writable local volume but with a service account
+// that can read/write a ConfigMap in its own namespace.
+type ConfigMapCache struct {
+	clientset kubernetes.Interface
+	namespace string
+	name      string
+}
+
+func NewConfigMapCache(clientset kubernetes.Interface, namespace, name string) *ConfigMapCache {
+	return &ConfigMapCache{clientset: clientset, namespace: namespace, name: name}
+}
+
+type configMapEnvelope struct {
+	Rules    v1alpha1.VerificationRulesTconfigd `json:"rules"`
+	CachedAt time.Time                          `json:"cachedAt"`
+}
+
+func (c *ConfigMapCache) Save(ctx context.Context, rules v1alpha1.VerificationRulesTconfigd) error {
+	envelope := configMapEnvelope{
+		Rules:    rules,
+		CachedAt: time.Now(),
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached rules: %w", err)
+	}
+
+	configMaps := c.clientset.CoreV1().ConfigMaps(c.namespace)
+
+	cm, err := configMaps.Get(ctx, c.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, createErr := configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: c.name, Namespace: c.namespace},
+			Data:       map[string]string{rulesDataKey: string(data)},
+		}, metav1.CreateOptions{})
+
+		if createErr != nil {
+			return fmt.Errorf("failed to create rule cache configmap: %w", createErr)
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get rule cache configmap: %w", err)
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	cm.Data[rulesDataKey] = string(data)
+
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update rule cache configmap: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ConfigMapCache) Load(ctx context.Context) (*CachedRules, error) {
+	cm, err := c.clientset.CoreV1().ConfigMaps(c.namespace).Get(ctx, c.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule cache configmap: %w", err)
+	}
+
+	raw, ok := cm.Data[rulesDataKey]
+	if !ok {
+		return nil, fmt.Errorf("rule cache configmap %s/%s has no %s key", c.namespace, c.name, rulesDataKey)
+	}
+
+	var envelope configMapEnvelope
+	if err := json.Unmarshal([]byte(raw), &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached rules: %w", err)
+	}
+
+	return &CachedRules{
+		Rules:    envelope.Rules,
+		CachedAt: envelope.CachedAt,
+		Source:   fmt.Sprintf("configmap:%s/%s", c.namespace, c.name),
+	}, nil
+}
@@ -0,0 +1,83 @@
+package rulecache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tratteria/tratteria-agent/verificationrules/v1alpha1"
+)
+
+// DefaultFileCachePath is where FileCache persists rules when the agent
+// doesn't override it, chosen to match a typical emptyDir/hostPath mount
+// for this sidecar.
+const DefaultFileCachePath = "/var/run/tratteria-agent/rules-cache.json"
+
+// FileCache persists rules as JSON on the local filesystem, the simplest
+// option for a single-node agent or any deployment with a writable local
+// volume.
+type FileCache struct {
+	path string
+}
+
+func NewFileCache(path string) *FileCache {
+	if path == "" {
+		path = DefaultFileCachePath
+	}
+
+	return &FileCache{path: path}
+}
+
+type fileCacheEnvelope struct {
+	Rules    v1alpha1.VerificationRulesTconfigd `json:"rules"`
+	CachedAt time.Time                          `json:"cachedAt"`
+}
+
+func (c *FileCache) Save(ctx context.Context, rules v1alpha1.VerificationRulesTconfigd) error {
+	envelope := fileCacheEnvelope{
+		Rules:    rules,
+		CachedAt: time.Now(),
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached rules: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create rule cache directory: %w", err)
+	}
+
+	tmpPath := c.path + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write rule cache file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("failed to finalize rule cache file: %w", err)
+	}
+
+	return nil
+}
+
+func (c *FileCache) Load(ctx context.Context) (*CachedRules, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule cache file: %w", err)
+	}
+
+	var envelope fileCacheEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached rules: %w", err)
+	}
+
+	return &CachedRules{
+		Rules:    envelope.Rules,
+		CachedAt: envelope.CachedAt,
+		Source:   "file:" + c.path,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package rulecache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tratteria/tratteria-agent/verificationrules/v1alpha1"
+)
+
+// InMemoryCache keeps the last rules in process memory only. It survives
+// a failed re-registration within the same process but not a restart;
+// useful for local development or as the default when no durable cache
+// is configured.
+type InMemoryCache struct {
+	mu       sync.RWMutex
+	rules    *v1alpha1.VerificationRulesTconfigd
+	cachedAt time.Time
+}
+
+func NewInMemoryCache() *InMemoryCache {
+	return &InMemoryCache{}
+}
+
+func (c *InMemoryCache) Save(ctx context.Context, rules v1alpha1.VerificationRulesTconfigd) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules = &rules
+	c.cachedAt = time.Now()
+
+	return nil
+}
+
+func (c *InMemoryCache) Load(ctx context.Context) (*CachedRules, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.rules == nil {
+		return nil, fmt.Errorf("no rules cached in memory")
+	}
+
+	return &CachedRules{
+		Rules:    *c.rules,
+		CachedAt: c.cachedAt,
+		Source:   "memory",
+	}, nil
+}
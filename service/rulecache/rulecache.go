@@ -0,0 +1,29 @@
+// Package rulecache persists the last verification rules an agent
+// received from tconfigd so a control-plane outage doesn't turn into a
+// data-plane outage: Client.Start falls back to the cache when initial
+// registration fails.
+package rulecache
+
+import (
+	"context"
+	"time"
+
+	"github.com/tratteria/tratteria-agent/verificationrules/v1alpha1"
+)
+
+// CachedRules is what a Cache returns on Load: the rules themselves, plus
+// enough metadata for the caller to decide whether they're still usable.
+type CachedRules struct {
+	Rules    v1alpha1.VerificationRulesTconfigd
+	CachedAt time.Time
+	Source   string
+}
+
+// Cache stores and retrieves the last-known-good verification rules.
+// Save is called after a successful registration or stream update whose
+// rules differ from the last applied set; Load is only consulted when
+// tconfigd can't be reached.
+type Cache interface {
+	Save(ctx context.Context, rules v1alpha1.VerificationRulesTconfigd) error
+	Load(ctx context.Context) (*CachedRules, error)
+}
@@ -0,0 +1,9 @@
+// Package version holds build-time metadata for the agent binary.
+package version
+
+// Version is the agent's build version, reported to tconfigd on
+// registration; heartbeats don't carry it. It's "dev" unless overridden at
+// build time, e.g.:
+//
+//	go build -ldflags "-X github.com/tratteria/tratteria-agent/service/version.Version=$(git describe --tags)"
+var Version = "dev"
@@ -0,0 +1,38 @@
+// Package v1alpha1 defines the verification rule types exchanged between
+// tconfigd and its agents, and the manager interface an agent uses to
+// apply rule updates, either as a full replacement snapshot or as an
+// incremental delta keyed by TraT name.
+package v1alpha1
+
+import "encoding/json"
+
+// VerificationRulesTconfigd is a set of TraT verification rules for a
+// single service. In a snapshot push it's the complete rule set; in a
+// delta push it's only the TraTs that were added or changed, paired with
+// a separate list of deleted TraT names.
+type VerificationRulesTconfigd struct {
+	TraTs map[string]TraTVerificationRule `json:"trats"`
+}
+
+// TraTVerificationRule is the verification rule for a single TraT (the
+// action/endpoint a request is tied to). Spec is left as raw JSON since
+// its shape evolves independently of an agent's reconciliation logic.
+type TraTVerificationRule struct {
+	Name string          `json:"name"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+// VerificationRulesManager holds the rules an agent currently enforces
+// and applies updates pushed by tconfigd.
+type VerificationRulesManager interface {
+	// UpdateCompleteRules replaces the entire rule set, used when
+	// tconfigd sends (or the agent falls back to requesting) a full
+	// snapshot.
+	UpdateCompleteRules(rules VerificationRulesTconfigd)
+
+	// ApplyRuleDelta merges an incremental update into the current rule
+	// set: upserts add or replace rules by name, and names listed in
+	// deletes are removed. It's used when tconfigd pushes an incremental
+	// delta instead of a full snapshot.
+	ApplyRuleDelta(upserts map[string]TraTVerificationRule, deletes []string)
+}